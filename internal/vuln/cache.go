@@ -0,0 +1,185 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vuln
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// indexTTLFactor is how much longer the modulesEndpoint and vulnsEndpoint
+// responses are cached relative to the TTL passed to NewClientWithCache.
+// Every ByPackage or ByAlias call re-fetches one of these two indexes, but
+// the indexes themselves change far less often than an individual entry,
+// so it's worth holding onto them longer between revalidations.
+const indexTTLFactor = 10
+
+// NewClientWithCache returns a client that reads from the HTTP vulnerability
+// database at src, caching responses in an in-memory LRU cache of size
+// entries. Cached responses are revalidated with a conditional GET once
+// they're older than ttl, so an unchanged response is never downloaded
+// again. Responses are cached as raw bytes, which the client decodes on
+// every call.
+func NewClientWithCache(src string, size int, ttl time.Duration) (*Client, error) {
+	cs, err := newCachingSource(src, size, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{src: cs}, nil
+}
+
+// CacheMetrics reports how effective a CachingSource's cache has been.
+type CacheMetrics struct {
+	Hits                     int64 // requests served from an unexpired cache entry
+	Misses                   int64 // requests that had to fetch a fresh response
+	ConditionalRevalidations int64 // requests served from an expired entry confirmed unchanged via a 304
+}
+
+// cacheEntry is a cached response, along with the validators needed to
+// revalidate it once it expires.
+type cacheEntry struct {
+	data         []byte
+	etag         string
+	lastModified string
+	expires      time.Time
+}
+
+// CachingSource is a source that wraps an HTTP vulnerability database with
+// an LRU cache keyed by endpoint path. Entries are revalidated with
+// conditional GETs (If-None-Match / If-Modified-Since) once they expire,
+// so a 304 response reuses the cached bytes without reading a new body.
+type CachingSource struct {
+	base     string
+	client   *http.Client
+	indexTTL time.Duration
+	entryTTL time.Duration
+
+	mu    sync.Mutex
+	cache *lru.Cache[string, *cacheEntry]
+
+	hits, misses, revalidations int64
+}
+
+func newCachingSource(src string, size int, ttl time.Duration) (*CachingSource, error) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("vuln: NewClientWithCache requires an http(s) source, got %q", src)
+	}
+	c, err := lru.New[string, *cacheEntry](size)
+	if err != nil {
+		return nil, err
+	}
+	return &CachingSource{
+		base:     strings.TrimSuffix(src, "/"),
+		client:   http.DefaultClient,
+		indexTTL: ttl * indexTTLFactor,
+		entryTTL: ttl,
+		cache:    c,
+	}, nil
+}
+
+// get implements the source interface.
+func (c *CachingSource) get(ctx context.Context, endpoint string) (_ []byte, err error) {
+	derrors.Wrap(&err, "CachingSource.get(%s)", endpoint)
+
+	c.mu.Lock()
+	entry, ok := c.cache.Get(endpoint)
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		atomic.AddInt64(&c.hits, 1)
+		return entry.data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/"+endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if entry.etag != "" {
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		atomic.AddInt64(&c.revalidations, 1)
+		// Entries are never mutated in place once cached, since other
+		// goroutines may be holding the same *cacheEntry from an earlier
+		// Get without holding c.mu. Store a fresh entry instead.
+		refreshed := &cacheEntry{
+			data:         entry.data,
+			etag:         entry.etag,
+			lastModified: entry.lastModified,
+			expires:      time.Now().Add(c.ttlFor(endpoint)),
+		}
+		c.mu.Lock()
+		c.cache.Add(endpoint, refreshed)
+		c.mu.Unlock()
+		return refreshed.data, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vuln: fetching %s: %s", endpoint, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	atomic.AddInt64(&c.misses, 1)
+	c.mu.Lock()
+	c.cache.Add(endpoint, &cacheEntry{
+		data:         data,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		expires:      time.Now().Add(c.ttlFor(endpoint)),
+	})
+	c.mu.Unlock()
+
+	return data, nil
+}
+
+// ttlFor returns how long a response for endpoint should be cached before
+// it needs revalidating.
+func (c *CachingSource) ttlFor(endpoint string) time.Duration {
+	switch endpoint {
+	case modulesEndpoint, vulnsEndpoint:
+		return c.indexTTL
+	default:
+		return c.entryTTL
+	}
+}
+
+// Metrics returns a snapshot of the cache's hit, miss, and
+// conditional-revalidation counters, so callers can tune its size and TTL.
+func (c *CachingSource) Metrics() CacheMetrics {
+	return CacheMetrics{
+		Hits:                     atomic.LoadInt64(&c.hits),
+		Misses:                   atomic.LoadInt64(&c.misses),
+		ConditionalRevalidations: atomic.LoadInt64(&c.revalidations),
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vuln
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler serves body for any request, tracking how many requests
+// it received and honoring If-None-Match with a 304.
+type countingHandler struct {
+	body string
+	etag string
+	hits int64
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&h.hits, 1)
+	w.Header().Set("ETag", h.etag)
+	if r.Header.Get("If-None-Match") == h.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write([]byte(h.body))
+}
+
+func TestCachingSource(t *testing.T) {
+	h := &countingHandler{body: `[]`, etag: `"v1"`}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	cs, err := newCachingSource(srv.URL, 10, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	// modulesEndpoint gets indexTTLFactor times the TTL (cache.go's ttlFor),
+	// so use a per-ID endpoint here to exercise the plain entryTTL path.
+	endpoint := filepath.Join(idDir, "GO-2023-0001")
+
+	// First request is a miss.
+	if _, err := cs.get(ctx, endpoint); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt64(&h.hits), int64(1); got != want {
+		t.Fatalf("requests to server = %d, want %d", got, want)
+	}
+
+	// A second request within the TTL is served from the cache, not the
+	// server.
+	if _, err := cs.get(ctx, endpoint); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt64(&h.hits), int64(1); got != want {
+		t.Fatalf("requests to server after cache hit = %d, want %d", got, want)
+	}
+
+	// Once the entry expires, a request revalidates with a conditional GET,
+	// which the server answers with a 304.
+	time.Sleep(30 * time.Millisecond)
+	if _, err := cs.get(ctx, endpoint); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt64(&h.hits), int64(2); got != want {
+		t.Fatalf("requests to server after expiry = %d, want %d", got, want)
+	}
+
+	m := cs.Metrics()
+	if m.Misses != 1 {
+		t.Errorf("Misses = %d, want 1", m.Misses)
+	}
+	if m.Hits != 1 {
+		t.Errorf("Hits = %d, want 1", m.Hits)
+	}
+	if m.ConditionalRevalidations != 1 {
+		t.Errorf("ConditionalRevalidations = %d, want 1", m.ConditionalRevalidations)
+	}
+}
+
+// TestCachingSource_ConcurrentRevalidation exercises the scenario the cache
+// is built for: many goroutines rendering package pages at once, all
+// hitting an expired entry and racing to revalidate it. Run with -race to
+// confirm the refreshed entry is never mutated in place.
+func TestCachingSource_ConcurrentRevalidation(t *testing.T) {
+	h := &countingHandler{body: `[]`, etag: `"v1"`}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	cs, err := newCachingSource(srv.URL, 10, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	// modulesEndpoint gets indexTTLFactor times the TTL (cache.go's ttlFor),
+	// so use a per-ID endpoint so the 10ms sleep below actually expires it.
+	endpoint := filepath.Join(idDir, "GO-2023-0001")
+	if _, err := cs.get(ctx, endpoint); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cs.get(ctx, endpoint); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewClientWithCache_RejectsNonHTTP(t *testing.T) {
+	if _, err := NewClientWithCache("file:///tmp/db", 10, time.Minute); err == nil {
+		t.Error("NewClientWithCache(file URL) = nil error, want non-nil")
+	}
+}
@@ -11,7 +11,9 @@ import (
 	"fmt"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/osv"
@@ -58,12 +60,68 @@ type PackageRequest struct {
 	// version.
 	// If empty, ByPackage will not filter based on version.
 	Version string
+	// GOOS is the GOOS to filter on.
+	// ByPackage will only return entries that affect this GOOS.
+	// If empty, ByPackage will not filter based on GOOS.
+	GOOS string
+	// GOARCH is the GOARCH to filter on.
+	// ByPackage will only return entries that affect this GOARCH.
+	// If empty, ByPackage will not filter based on GOARCH.
+	GOARCH string
+	// IncludeWithdrawn controls whether entries that have been withdrawn
+	// are included in the result. By default, ByPackage omits entries
+	// whose Withdrawn time is non-zero and in the past.
+	IncludeWithdrawn bool
 }
 
 // ByPackage returns the OSV entries matching the package request.
 func (c *Client) ByPackage(ctx context.Context, req *PackageRequest) (_ []*osv.Entry, err error) {
 	derrors.Wrap(&err, "ByPackage(%v)", req)
 
+	matches, err := c.ByPackageDetailed(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*osv.Entry, len(matches))
+	for i, m := range matches {
+		entries[i] = m.Entry
+	}
+	return entries, nil
+}
+
+// PackageMatch is an OSV entry matching a PackageRequest, together with the
+// symbols and platforms of the requested package that the entry declares
+// as affected.
+type PackageMatch struct {
+	Entry *osv.Entry
+	// SchemaVersion is Entry.SchemaVersion, surfaced directly so that
+	// callers can branch on schema differences without reaching into
+	// Entry themselves.
+	SchemaVersion string
+	// AffectedSymbols is the set of symbols in req.Package that Entry
+	// declares as affected. A nil AffectedSymbols means the whole package
+	// is affected.
+	AffectedSymbols []string
+	// Platforms lists the GOOS/GOARCH constraints, if any, that Entry
+	// declares for req.Package.
+	Platforms []PlatformConstraint
+}
+
+// PlatformConstraint is a GOOS/GOARCH pair restricting the platforms an
+// osv.Package's affectedness applies to, as declared by that package's GOOS
+// and GOARCH fields.
+type PlatformConstraint struct {
+	GOOS   []string
+	GOARCH []string
+}
+
+// ByPackageDetailed is like ByPackage, but for each matching entry it also
+// reports which symbols and platforms of req.Package the entry actually
+// declares as affected, sparing callers from re-deriving that from the raw
+// osv.Entry themselves.
+func (c *Client) ByPackageDetailed(ctx context.Context, req *PackageRequest) (_ []*PackageMatch, err error) {
+	derrors.Wrap(&err, "ByPackageDetailed(%v)", req)
+
 	b, err := c.modules(ctx)
 	if err != nil {
 		return nil, err
@@ -104,11 +162,11 @@ func (c *Client) ByPackage(ctx context.Context, req *PackageRequest) (_ []*osv.E
 	g, gctx := errgroup.WithContext(ctx)
 	var mux sync.Mutex
 	g.SetLimit(10)
-	entries := make([]*osv.Entry, 0, len(ids))
+	matches := make([]*PackageMatch, 0, len(ids))
 	for _, id := range ids {
 		id := id
 		g.Go(func() error {
-			entry, err := c.ByID(gctx, id)
+			entry, err := c.fetchEntry(gctx, id)
 			if err != nil {
 				return err
 			}
@@ -117,7 +175,154 @@ func (c *Client) ByPackage(ctx context.Context, req *PackageRequest) (_ []*osv.E
 				return fmt.Errorf("vulnerability %s was found in %s but could not be retrieved", id, modulesEndpoint)
 			}
 
+			if isWithdrawn(entry, req.IncludeWithdrawn) {
+				return nil
+			}
+
 			if isAffected(entry, req) {
+				mux.Lock()
+				matches = append(matches, &PackageMatch{
+					Entry:           entry,
+					SchemaVersion:   entry.SchemaVersion,
+					AffectedSymbols: affectedSymbols(entry, req),
+					Platforms:       affectedPlatforms(entry, req),
+				})
+				mux.Unlock()
+			}
+
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Entry.ID < matches[j].Entry.ID
+	})
+
+	return matches, nil
+}
+
+// affectedSymbols returns the symbols that e declares as affected in
+// req.Module, req.Package, and req.Version. A nil result means e affects
+// the whole package.
+func affectedSymbols(e *osv.Entry, req *PackageRequest) []string {
+	var symbols []string
+	for _, a := range e.Affected {
+		if a.Module.Path != req.Module || !osv.AffectsSemver(a.Ranges, req.Version) {
+			continue
+		}
+		for _, p := range a.EcosystemSpecific.Packages {
+			if req.Package != "" && p.Path != req.Package {
+				continue
+			}
+			if len(p.Symbols) == 0 {
+				return nil // no symbols declared, so the whole package is affected
+			}
+			symbols = append(symbols, p.Symbols...)
+		}
+	}
+	return dedupSorted(symbols)
+}
+
+// affectedPlatforms returns the GOOS/GOARCH constraints that e declares for
+// req.Module, req.Package, and req.Version.
+func affectedPlatforms(e *osv.Entry, req *PackageRequest) []PlatformConstraint {
+	var platforms []PlatformConstraint
+	for _, a := range e.Affected {
+		if a.Module.Path != req.Module || !osv.AffectsSemver(a.Ranges, req.Version) {
+			continue
+		}
+		for _, p := range a.EcosystemSpecific.Packages {
+			if req.Package != "" && p.Path != req.Package {
+				continue
+			}
+			if len(p.GOOS) == 0 && len(p.GOARCH) == 0 {
+				continue
+			}
+			platforms = append(platforms, PlatformConstraint{GOOS: p.GOOS, GOARCH: p.GOARCH})
+		}
+	}
+	return platforms
+}
+
+// dedupSorted returns the sorted, deduplicated contents of ss.
+func dedupSorted(ss []string) []string {
+	if len(ss) == 0 {
+		return nil
+	}
+	sort.Strings(ss)
+	out := ss[:1]
+	for _, s := range ss[1:] {
+		if s != out[len(out)-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ByPackagePrefix returns the OSV entries that affect the given module or
+// package prefix, or any package nested under it. Unlike ByPackage, it does
+// not filter by version, so it is meant for scoping a vuln listing to a
+// module or directory (e.g. a vuln-list page) rather than for checking
+// whether a particular build is affected.
+func (c *Client) ByPackagePrefix(ctx context.Context, prefix string) (_ []*osv.Entry, err error) {
+	derrors.Wrap(&err, "ByPackagePrefix(%s)", prefix)
+
+	b, err := c.modules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dec, err := newStreamDecoder(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for dec.More() {
+		var m ModuleMeta
+		if err := dec.Decode(&m); err != nil {
+			return nil, err
+		}
+		if pathMatchesPrefix(m.Path, prefix) {
+			for _, v := range m.Vulns {
+				// An entry affecting multiple modules that both match
+				// prefix (e.g. both "std" and "cmd") is listed once per
+				// module in the index; dedup so it's only fetched once.
+				if !seen[v.ID] {
+					seen[v.ID] = true
+					ids = append(ids, v.ID)
+				}
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	// Fetch all the candidate entries in parallel, and keep only the ones
+	// actually affecting the prefix.
+	g, gctx := errgroup.WithContext(ctx)
+	var mux sync.Mutex
+	g.SetLimit(10)
+	entries := make([]*osv.Entry, 0, len(ids))
+	for _, id := range ids {
+		id := id
+		g.Go(func() error {
+			entry, err := c.fetchEntry(gctx, id)
+			if err != nil {
+				return err
+			}
+
+			if entry == nil {
+				return fmt.Errorf("vulnerability %s was found in %s but could not be retrieved", id, modulesEndpoint)
+			}
+
+			if affectsPackagePrefix(entry, prefix) {
 				mux.Lock()
 				entries = append(entries, entry)
 				mux.Unlock()
@@ -137,25 +342,65 @@ func (c *Client) ByPackage(ctx context.Context, req *PackageRequest) (_ []*osv.E
 	return entries, nil
 }
 
+// affectsPackagePrefix reports whether e affects prefix. It matches if the
+// module itself is exactly prefix (e.g. a whole-stdlib query for "std",
+// whose package paths like "net/http" don't share "std" as a textual
+// prefix), or if a declared package path is at or under prefix.
+func affectsPackagePrefix(e *osv.Entry, prefix string) bool {
+	for _, a := range e.Affected {
+		if a.Module.Path == prefix {
+			return true
+		}
+		if len(a.EcosystemSpecific.Packages) == 0 {
+			if pathMatchesPrefix(a.Module.Path, prefix) {
+				return true
+			}
+			continue
+		}
+		for _, p := range a.EcosystemSpecific.Packages {
+			if p.Path == prefix || strings.HasPrefix(p.Path+"/", prefix+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pathMatchesPrefix reports whether path is prefix, is nested under
+// prefix, or itself contains prefix as a nested path. The second case
+// lets a directory prefix (e.g. "example.com") match a module that lives
+// below it; the third lets a package prefix (e.g. "cmd/go") match the
+// module-index entry of the module that contains it (e.g. "cmd").
+func pathMatchesPrefix(path, prefix string) bool {
+	return path == prefix ||
+		strings.HasPrefix(path+"/", prefix+"/") ||
+		strings.HasPrefix(prefix+"/", path+"/")
+}
+
 func isAffected(e *osv.Entry, req *PackageRequest) bool {
 	for _, a := range e.Affected {
 		if a.Module.Path != req.Module || !osv.AffectsSemver(a.Ranges, req.Version) {
 			continue
 		}
-		if packageMatches := func() bool {
-			if req.Package == "" {
-				return true //  match module only
-			}
-			if len(a.EcosystemSpecific.Packages) == 0 {
-				return true // no package info available, so match on module
-			}
-			for _, p := range a.EcosystemSpecific.Packages {
-				if req.Package == p.Path {
-					return true // package matches
-				}
-			}
-			return false
-		}(); !packageMatches {
+		if packageMatches(a, req) {
+			return true
+		}
+	}
+	return false
+}
+
+// packageMatches reports whether a satisfies req's package, GOOS, and
+// GOARCH constraints. An Affected with multiple packages matches if any
+// one of them satisfies all the constraints that req sets.
+func packageMatches(a osv.Affected, req *PackageRequest) bool {
+	if len(a.EcosystemSpecific.Packages) == 0 {
+		return true // no package info available, so match on module
+	}
+	for _, p := range a.EcosystemSpecific.Packages {
+		if req.Package != "" && req.Package != p.Path {
+			continue
+		}
+		if !platformMatches(p.GOOS, req.GOOS) || !platformMatches(p.GOARCH, req.GOARCH) {
 			continue
 		}
 		return true
@@ -163,11 +408,49 @@ func isAffected(e *osv.Entry, req *PackageRequest) bool {
 	return false
 }
 
-// ByID returns the OSV entry with the given ID or (nil, nil)
-// if there isn't one.
+// platformMatches reports whether platform satisfies the constraint
+// declared by list, where list is an osv.Package's GOOS or GOARCH field.
+// An empty list, or an empty platform, matches anything.
+func platformMatches(list []string, platform string) bool {
+	if len(list) == 0 || platform == "" {
+		return true
+	}
+	for _, p := range list {
+		if p == platform {
+			return true
+		}
+	}
+	return false
+}
+
+// isWithdrawn reports whether e has been withdrawn as of now and
+// includeWithdrawn is false. An entry whose Withdrawn time is in the
+// future (a scheduled withdrawal) is not yet considered withdrawn.
+func isWithdrawn(e *osv.Entry, includeWithdrawn bool) bool {
+	return !includeWithdrawn && !e.Withdrawn.IsZero() && !e.Withdrawn.After(time.Now())
+}
+
+// ByID returns the OSV entry with the given ID, or (nil, nil) if there
+// isn't one or it has been withdrawn.
 func (c *Client) ByID(ctx context.Context, id string) (_ *osv.Entry, err error) {
 	derrors.Wrap(&err, "ByID(%s)", id)
 
+	entry, err := c.fetchEntry(ctx, id)
+	if err != nil || entry == nil {
+		return entry, err
+	}
+	if isWithdrawn(entry, false) {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// fetchEntry returns the raw OSV entry with the given ID, or (nil, nil) if
+// there isn't one, without filtering out withdrawn entries. Callers that
+// need to tell "not found" apart from "found but withdrawn" (to apply
+// PackageRequest.IncludeWithdrawn, for instance) should use this instead
+// of ByID.
+func (c *Client) fetchEntry(ctx context.Context, id string) (_ *osv.Entry, err error) {
 	b, err := c.entry(ctx, id)
 	if err != nil {
 		// entry only fails if the entry is not found, so do not return
@@ -214,7 +497,8 @@ func (c *Client) ByAlias(ctx context.Context, alias string) (_ string, err error
 	return "", derrors.NotFound
 }
 
-// Entries returns all entries in the database.
+// Entries returns all entries in the database, excluding any that have
+// been withdrawn.
 func (c *Client) Entries(ctx context.Context) (_ []*osv.Entry, err error) {
 	derrors.Wrap(&err, "Entries()")
 
@@ -229,6 +513,7 @@ func (c *Client) Entries(ctx context.Context) (_ []*osv.Entry, err error) {
 	for i, id := range ids {
 		i, id := i, id
 		g.Go(func() error {
+			// ByID already omits withdrawn entries by default.
 			e, err := c.ByID(gctx, id)
 			if err != nil {
 				return err
@@ -241,7 +526,42 @@ func (c *Client) Entries(ctx context.Context) (_ []*osv.Entry, err error) {
 		return nil, err
 	}
 
-	return entries, nil
+	out := entries[:0]
+	for _, e := range entries {
+		if e != nil {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// ByModified returns the IDs of the entries in the database that were last
+// modified at or after since, for use by incremental refresh workflows.
+func (c *Client) ByModified(ctx context.Context, since time.Time) (_ []string, err error) {
+	derrors.Wrap(&err, "ByModified(%s)", since)
+
+	b, err := c.vulns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dec, err := newStreamDecoder(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for dec.More() {
+		var v VulnMeta
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		if !v.Modified.Before(since) {
+			ids = append(ids, v.ID)
+		}
+	}
+
+	return ids, nil
 }
 
 // ids returns a list of the ids of all the entries in the database.
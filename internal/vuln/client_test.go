@@ -0,0 +1,467 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vuln
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tailscale/pkgsitelib/internal/osv"
+)
+
+func TestByPackagePrefix(t *testing.T) {
+	mod := osv.Entry{
+		ID: "GO-1999-0001",
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "example.com/mod"},
+			EcosystemSpecific: osv.EcosystemSpecific{
+				Packages: []osv.Package{{
+					Path: "example.com/mod",
+				}, {
+					Path: "example.com/mod/sub",
+				}},
+			},
+		}},
+	}
+	other := osv.Entry{
+		ID: "GO-1999-0002",
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "example.com/other"},
+			EcosystemSpecific: osv.EcosystemSpecific{
+				Packages: []osv.Package{{
+					Path: "example.com/other",
+				}},
+			},
+		}},
+	}
+	stdNetHTTP := osv.Entry{
+		ID: "GO-2000-0003",
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "std"},
+			EcosystemSpecific: osv.EcosystemSpecific{
+				Packages: []osv.Package{{
+					Path: "net/http",
+				}},
+			},
+		}},
+	}
+	cmdGo := osv.Entry{
+		ID: "GO-2000-0004",
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "cmd"},
+			EcosystemSpecific: osv.EcosystemSpecific{
+				Packages: []osv.Package{{
+					Path: "cmd/go",
+				}},
+			},
+		}},
+	}
+	// sharedPrefix affects two distinct modules that both match the
+	// "example.com" prefix, and so is listed under both in the module
+	// index; it must still appear only once in the result.
+	sharedPrefix := osv.Entry{
+		ID: "GO-1999-0005",
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "example.com/mod"},
+			EcosystemSpecific: osv.EcosystemSpecific{
+				Packages: []osv.Package{{
+					Path: "example.com/mod",
+				}},
+			},
+		}, {
+			Module: osv.Module{Path: "example.com/other"},
+			EcosystemSpecific: osv.EcosystemSpecific{
+				Packages: []osv.Package{{
+					Path: "example.com/other",
+				}},
+			},
+		}},
+	}
+
+	client, err := NewInMemoryClient([]*osv.Entry{&mod, &other, &stdNetHTTP, &cmdGo, &sharedPrefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{
+			name:   "exact module",
+			prefix: "example.com/mod",
+			want:   []string{"GO-1999-0001", "GO-1999-0005"},
+		},
+		{
+			name:   "sub-package",
+			prefix: "example.com/mod/sub",
+			want:   []string{"GO-1999-0001"},
+		},
+		{
+			name:   "directory above the module",
+			prefix: "example.com",
+			want:   []string{"GO-1999-0001", "GO-1999-0002", "GO-1999-0005"},
+		},
+		{
+			name:   "no match",
+			prefix: "example.com/nothing",
+			want:   nil,
+		},
+		{
+			name:   "whole stdlib module",
+			prefix: "std",
+			want:   []string{"GO-2000-0003"},
+		},
+		{
+			name:   "package within cmd",
+			prefix: "cmd/go",
+			want:   []string{"GO-2000-0004"},
+		},
+		{
+			name:   "whole cmd module",
+			prefix: "cmd",
+			want:   []string{"GO-2000-0004"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := client.ByPackagePrefix(context.Background(), test.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, e := range got {
+				ids = append(ids, e.ID)
+			}
+			sort.Strings(ids)
+			if diff := cmp.Diff(test.want, ids); diff != "" {
+				t.Errorf("ByPackagePrefix(%q) mismatch (-want +got):\n%s", test.prefix, diff)
+			}
+		})
+	}
+}
+
+func TestByPackage_Platform(t *testing.T) {
+	windowsOnly := osv.Entry{
+		ID: "GO-2023-0001",
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "example.com/mod"},
+			EcosystemSpecific: osv.EcosystemSpecific{
+				Packages: []osv.Package{{
+					Path: "example.com/mod",
+					GOOS: []string{"windows"},
+				}},
+			},
+		}},
+	}
+	anyPlatform := osv.Entry{
+		ID: "GO-2023-0002",
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "example.com/mod"},
+			EcosystemSpecific: osv.EcosystemSpecific{
+				Packages: []osv.Package{{
+					Path: "example.com/mod",
+				}},
+			},
+		}},
+	}
+	mixedPackages := osv.Entry{
+		ID: "GO-2023-0003",
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "example.com/mod"},
+			EcosystemSpecific: osv.EcosystemSpecific{
+				Packages: []osv.Package{{
+					Path:   "example.com/mod/linuxonly",
+					GOOS:   []string{"linux"},
+					GOARCH: []string{"amd64"},
+				}, {
+					Path: "example.com/mod/any",
+				}},
+			},
+		}},
+	}
+
+	client, err := NewInMemoryClient([]*osv.Entry{&windowsOnly, &anyPlatform, &mixedPackages})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  *PackageRequest
+		want []string
+	}{
+		{
+			name: "windows-only entry rejected for linux",
+			req:  &PackageRequest{Module: "example.com/mod", Package: "example.com/mod", GOOS: "linux"},
+			want: []string{"GO-2023-0002"},
+		},
+		{
+			name: "windows-only entry accepted for windows",
+			req:  &PackageRequest{Module: "example.com/mod", Package: "example.com/mod", GOOS: "windows"},
+			want: []string{"GO-2023-0001", "GO-2023-0002"},
+		},
+		{
+			name: "empty GOOS accepted for any platform",
+			req:  &PackageRequest{Module: "example.com/mod", Package: "example.com/mod"},
+			want: []string{"GO-2023-0001", "GO-2023-0002"},
+		},
+		{
+			name: "mixed-package entry matches on the package that satisfies the platform",
+			req:  &PackageRequest{Module: "example.com/mod", GOOS: "linux", GOARCH: "amd64"},
+			want: []string{"GO-2023-0002", "GO-2023-0003"},
+		},
+		{
+			name: "mixed-package entry still matches a platform neither package declares, via the unconstrained package",
+			req:  &PackageRequest{Module: "example.com/mod", GOOS: "darwin", GOARCH: "arm64"},
+			want: []string{"GO-2023-0002", "GO-2023-0003"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := client.ByPackage(context.Background(), test.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, e := range got {
+				ids = append(ids, e.ID)
+			}
+			sort.Strings(ids)
+			if diff := cmp.Diff(test.want, ids); diff != "" {
+				t.Errorf("ByPackage(%+v) mismatch (-want +got):\n%s", test.req, diff)
+			}
+		})
+	}
+}
+
+func TestByPackageDetailed(t *testing.T) {
+	wholePackage := osv.Entry{
+		ID:            "GO-2023-0010",
+		SchemaVersion: "1.3.1",
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "example.com/mod"},
+			EcosystemSpecific: osv.EcosystemSpecific{
+				Packages: []osv.Package{{
+					Path: "example.com/mod",
+				}},
+			},
+		}},
+	}
+	withSymbols := osv.Entry{
+		ID: "GO-2023-0011",
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "example.com/mod"},
+			EcosystemSpecific: osv.EcosystemSpecific{
+				Packages: []osv.Package{{
+					Path:    "example.com/mod",
+					Symbols: []string{"F", "G"},
+					GOOS:    []string{"linux"},
+				}},
+			},
+		}},
+	}
+
+	client, err := NewInMemoryClient([]*osv.Entry{&wholePackage, &withSymbols})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &PackageRequest{Module: "example.com/mod", Package: "example.com/mod", Version: "v1.0.0"}
+	got, err := client.ByPackageDetailed(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byID := map[string]*PackageMatch{}
+	for _, m := range got {
+		byID[m.Entry.ID] = m
+	}
+
+	if m := byID["GO-2023-0010"]; m.AffectedSymbols != nil {
+		t.Errorf("AffectedSymbols for whole-package entry = %v, want nil", m.AffectedSymbols)
+	}
+	if got, want := byID["GO-2023-0010"].SchemaVersion, "1.3.1"; got != want {
+		t.Errorf("SchemaVersion = %q, want %q", got, want)
+	}
+
+	m := byID["GO-2023-0011"]
+	if diff := cmp.Diff([]string{"F", "G"}, m.AffectedSymbols); diff != "" {
+		t.Errorf("AffectedSymbols mismatch (-want +got):\n%s", diff)
+	}
+	if len(m.Platforms) != 1 || m.Platforms[0].GOOS[0] != "linux" {
+		t.Errorf("Platforms = %+v, want a single linux constraint", m.Platforms)
+	}
+
+	// ByPackage is a thin wrapper that drops the detail.
+	entries, err := client.ByPackage(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(got) {
+		t.Errorf("ByPackage returned %d entries, ByPackageDetailed returned %d matches", len(entries), len(got))
+	}
+}
+
+func TestByPackageDetailed_VersionScopedRanges(t *testing.T) {
+	e := osv.Entry{
+		ID: "GO-2023-0012",
+		Affected: []osv.Affected{
+			{
+				Module: osv.Module{Path: "example.com/mod"},
+				Ranges: []osv.Range{{
+					Type:   osv.RangeTypeSemver,
+					Events: []osv.RangeEvent{{Introduced: "0"}, {Fixed: "1.0.0"}},
+				}},
+				EcosystemSpecific: osv.EcosystemSpecific{
+					Packages: []osv.Package{{
+						Path:    "example.com/mod",
+						Symbols: []string{"Old"},
+					}},
+				},
+			},
+			{
+				Module: osv.Module{Path: "example.com/mod"},
+				Ranges: []osv.Range{{
+					Type:   osv.RangeTypeSemver,
+					Events: []osv.RangeEvent{{Introduced: "1.0.0"}},
+				}},
+				EcosystemSpecific: osv.EcosystemSpecific{
+					Packages: []osv.Package{{
+						Path:    "example.com/mod",
+						Symbols: []string{"New"},
+					}},
+				},
+			},
+		},
+	}
+
+	client, err := NewInMemoryClient([]*osv.Entry{&e})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &PackageRequest{Module: "example.com/mod", Package: "example.com/mod", Version: "v2.0.0"}
+	got, err := client.ByPackageDetailed(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("ByPackageDetailed returned %d matches, want 1", len(got))
+	}
+	if diff := cmp.Diff([]string{"New"}, got[0].AffectedSymbols); diff != "" {
+		t.Errorf("AffectedSymbols mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestByPackage_Withdrawn(t *testing.T) {
+	active := osv.Entry{
+		ID: "GO-2023-0020",
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "example.com/mod"},
+		}},
+	}
+	withdrawn := osv.Entry{
+		ID:        "GO-2023-0021",
+		Withdrawn: time.Now().Add(-time.Hour),
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "example.com/mod"},
+		}},
+	}
+	withdrawnInFuture := osv.Entry{
+		ID:        "GO-2023-0022",
+		Withdrawn: time.Now().Add(time.Hour),
+		Affected: []osv.Affected{{
+			Module: osv.Module{Path: "example.com/mod"},
+		}},
+	}
+
+	client, err := NewInMemoryClient([]*osv.Entry{&active, &withdrawn, &withdrawnInFuture})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &PackageRequest{Module: "example.com/mod", Version: "v1.0.0"}
+	got, err := client.ByPackage(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, e := range got {
+		ids = append(ids, e.ID)
+	}
+	sort.Strings(ids)
+	want := []string{"GO-2023-0020", "GO-2023-0022"}
+	if diff := cmp.Diff(want, ids); diff != "" {
+		t.Errorf("ByPackage (withdrawn excluded) mismatch (-want +got):\n%s", diff)
+	}
+
+	reqIncl := &PackageRequest{Module: "example.com/mod", Version: "v1.0.0", IncludeWithdrawn: true}
+	got, err = client.ByPackage(context.Background(), reqIncl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids = nil
+	for _, e := range got {
+		ids = append(ids, e.ID)
+	}
+	sort.Strings(ids)
+	want = []string{"GO-2023-0020", "GO-2023-0021", "GO-2023-0022"}
+	if diff := cmp.Diff(want, ids); diff != "" {
+		t.Errorf("ByPackage (IncludeWithdrawn) mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestByModified(t *testing.T) {
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	old := osv.Entry{ID: "GO-2022-0001", Modified: t0.Add(-24 * time.Hour)}
+	boundary := osv.Entry{ID: "GO-2023-0001", Modified: t0}
+	recent := osv.Entry{ID: "GO-2023-0002", Modified: t0.Add(24 * time.Hour)}
+
+	client, err := NewInMemoryClient([]*osv.Entry{&old, &boundary, &recent})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.ByModified(context.Background(), t0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{"GO-2023-0001", "GO-2023-0002"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ByModified(%s) mismatch (-want +got):\n%s", t0, diff)
+	}
+}
+
+func TestByID_Withdrawn(t *testing.T) {
+	active := osv.Entry{ID: "GO-2023-0030"}
+	withdrawn := osv.Entry{ID: "GO-2023-0031", Withdrawn: time.Now().Add(-time.Hour)}
+
+	client, err := NewInMemoryClient([]*osv.Entry{&active, &withdrawn})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.ByID(context.Background(), "GO-2023-0030")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Error("ByID(active) = nil, want the entry")
+	}
+
+	got, err = client.ByID(context.Background(), "GO-2023-0031")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("ByID(withdrawn) = %v, want nil", got)
+	}
+}